@@ -0,0 +1,147 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// AuthScheme selects how serializeLoginMessageAuth authenticates the
+// connection with the VoltDB server.
+type AuthScheme int8
+
+const (
+	// AuthHashed sends a SHA-1 (protocol v0) or SHA-256 (protocol v1+)
+	// digest of the password, as VoltDB's built-in HASHED scheme expects.
+	// This is the client's historical, default behavior.
+	AuthHashed AuthScheme = iota
+	// AuthKerberos negotiates with a KDC and sends a SPNEGO-wrapped
+	// AP-REQ token in place of the password hash.
+	AuthKerberos
+)
+
+// AuthConfig carries the Kerberos/GSSAPI parameters needed to
+// authenticate against a VoltDB cluster configured for the KERBEROS
+// auth scheme. It is ignored when Scheme is AuthHashed.
+type AuthConfig struct {
+	Scheme AuthScheme
+
+	// ServicePrincipal is the VoltDB server's principal, of the form
+	// "service/host@REALM".
+	ServicePrincipal string
+
+	// KeytabPath, if set, is used to acquire a TGT for the connecting
+	// principal. CredentialCachePath takes precedence when both are set.
+	KeytabPath string
+
+	// CredentialCachePath, if set, points at an existing ccache (e.g. one
+	// populated by kinit) used instead of a keytab.
+	CredentialCachePath string
+
+	// Realm and Username identify the client principal when
+	// authenticating via KeytabPath.
+	Realm    string
+	Username string
+
+	// KrbConfPath is the path to krb5.conf. Defaults to "/etc/krb5.conf"
+	// when empty.
+	KrbConfPath string
+
+	// ChannelBinding, when non-nil, is intended to be bound into the
+	// GSS-API context establishment to tie the Kerberos exchange to this
+	// TLS channel. gokrb5's spnego.SPNEGO client does not currently
+	// expose a way to supply channel-binding data, so this is accepted
+	// but not yet wired in; see spnegoToken.
+	ChannelBinding []byte
+}
+
+func (a *AuthConfig) krbConfPath() string {
+	if a == nil || a.KrbConfPath == "" {
+		return "/etc/krb5.conf"
+	}
+	return a.KrbConfPath
+}
+
+// krbClient builds a gokrb5 client from the credential cache or keytab
+// named in auth, ready to negotiate a service ticket.
+func krbClient(auth *AuthConfig) (*client.Client, error) {
+	cfg, err := config.Load(auth.krbConfPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.CredentialCachePath != "" {
+		ccache, err := credentials.LoadCCache(auth.CredentialCachePath)
+		if err != nil {
+			return nil, err
+		}
+		return client.NewFromCCache(ccache, cfg)
+	}
+
+	if auth.KeytabPath == "" {
+		return nil, errors.New("voltdbclient: AuthConfig needs CredentialCachePath or KeytabPath for AuthKerberos")
+	}
+
+	kt, err := keytab.Load(auth.KeytabPath)
+	if err != nil {
+		return nil, err
+	}
+	cl := client.NewWithKeytab(auth.Username, auth.Realm, kt, cfg)
+	if err := cl.Login(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// spnegoToken obtains a service ticket for auth.ServicePrincipal and
+// returns the marshalled SPNEGO token ready to be written into the
+// login frame in place of the password hash.
+func spnegoToken(auth *AuthConfig) ([]byte, error) {
+	cl, err := krbClient(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	spnegoClient := spnego.SPNEGOClient(cl, auth.ServicePrincipal)
+	if err := spnegoClient.AcquireCred(); err != nil {
+		return nil, err
+	}
+	tkn, err := spnegoClient.InitSecContext()
+	if err != nil {
+		return nil, err
+	}
+	return tkn.Marshal()
+}
+
+// writeKerberosToken writes the SPNEGO/AP-REQ token into the login
+// frame the way writePasswordBytes writes a password hash: a length
+// prefix followed by the raw bytes.
+func writeKerberosToken(buf *bytes.Buffer, auth *AuthConfig) error {
+	tkn, err := spnegoToken(auth)
+	if err != nil {
+		return err
+	}
+	return writeVarbinary(buf, tkn)
+}
@@ -0,0 +1,52 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"net"
+	"time"
+)
+
+// connectAuth is the connection factory's dial-and-login entry point:
+// it opens network/addr - over TLS when tlsCfg is non-nil, per
+// dialVolt - then performs the login handshake for auth (nil meaning
+// the default HASHED scheme), returning the authenticated connection
+// and the server's advertisement.
+func connectAuth(network, addr string, timeout time.Duration, protocolVersion int, user, passwd string, auth *AuthConfig, tlsCfg *TLSConfig) (net.Conn, *connectionData, error) {
+	conn, err := dialVolt(network, addr, timeout, tlsCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg, err := serializeLoginMessageAuth(protocolVersion, user, passwd, auth)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	connData, err := deserializeLoginResponseAuth(conn, auth)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, connData, nil
+}
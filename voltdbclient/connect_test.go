@@ -0,0 +1,95 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestConnectAuthFakeListener exercises connectAuth end to end against
+// a real TCP listener standing in for a VoltDB server: dialVolt's
+// plaintext path, serializeLoginMessageAuth's login frame, and
+// deserializeLoginResponseAuth's parsing of the reply, all driven the
+// way a connection factory actually would.
+func TestConnectAuthFakeListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer ln.Close()
+
+	wantMsg, err := serializeLoginMessageAuth(1, "user", "pass", nil)
+	if err != nil {
+		t.Fatalf("serializeLoginMessageAuth: %v", err)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+
+		got := make([]byte, wantMsg.Len())
+		if _, err := io.ReadFull(conn, got); err != nil {
+			serverErr <- fmt.Errorf("reading login frame: %w", err)
+			return
+		}
+		if !bytes.Equal(got, wantMsg.Bytes()) {
+			serverErr <- fmt.Errorf("login frame mismatch: got % x, want % x", got, wantMsg.Bytes())
+			return
+		}
+
+		var resp bytes.Buffer
+		writeByte(&resp, 0)         // authentication ok
+		writeInt(&resp, 3)          // host ID
+		writeLong(&resp, 99)        // connection ID
+		writeLong(&resp, 12345)     // cluster start timestamp
+		writeInt(&resp, 0x0a000001) // leader address
+		writeString(&resp, "voltdb-8.4 fakehash")
+		if _, err := conn.Write(resp.Bytes()); err != nil {
+			serverErr <- fmt.Errorf("writing login response: %w", err)
+			return
+		}
+		serverErr <- nil
+	}()
+
+	conn, connData, err := connectAuth("tcp", ln.Addr().String(), 2*time.Second, 1, "user", "pass", nil, nil)
+	if err != nil {
+		t.Fatalf("connectAuth: %v", err)
+	}
+	defer conn.Close()
+
+	if err := <-serverErr; err != nil {
+		t.Fatalf("fake server: %v", err)
+	}
+
+	if connData.hostID != 3 || connData.connID != 99 || connData.leaderAddr != 0x0a000001 {
+		t.Fatalf("got %+v", connData)
+	}
+	if connData.buildString != "voltdb-8.4 fakehash" {
+		t.Fatalf("got build string %q", connData.buildString)
+	}
+}
@@ -0,0 +1,317 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// DumpParams writes a protobuf-text-style, indented rendering of
+// params to w: one "param[i] type: value" line per parameter, e.g.
+//
+//	param[0] int32: 42
+//	param[1] array<string>[3]: ["a", "b", "c"]
+//	param[2] timestamp: 2024-01-02T03:04:05Z
+//	param[3] null<varbinary>
+//
+// Each parameter is marshalled through the same Encoder used on the
+// wire and then decoded back by dumpValue, so the dump can never
+// drift from what actually goes on the wire. DumpParams does not
+// itself emit a leading parameter count: a caller dumping a frame
+// captured off the wire, which is prefixed with one, wants DumpFrame
+// instead.
+func DumpParams(w io.Writer, params ...interface{}) error {
+	enc := GetEncoder()
+	defer PutEncoder(enc)
+
+	for i, p := range params {
+		enc.Reset()
+		if err := marshallParamTo(enc, p); err != nil {
+			return fmt.Errorf("voltdbclient: dumping param[%d]: %w", i, err)
+		}
+		if _, err := fmt.Fprintf(w, "param[%d] ", i); err != nil {
+			return err
+		}
+		if err := dumpValue(w, bytes.NewReader(enc.Bytes())); err != nil {
+			return fmt.Errorf("voltdbclient: dumping param[%d]: %w", i, err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpFrame reads a VoltDB parameter-set frame from r - a 2-byte
+// parameter count followed by that many tagged values, the layout
+// marshallSliceTo also uses for ARRAY columns - and writes the same
+// "param[i] type: value" text DumpParams produces for an in-memory
+// parameter list. It is the read-side counterpart of a wire frame, not
+// of DumpParams: DumpParams' output has no count prefix of its own (it
+// already knows len(params)), so feeding DumpParams' text back into
+// DumpFrame is not a supported round trip. Use DumpFrame only on bytes
+// captured off the wire, where the count prefix is actually present.
+func DumpFrame(w io.Writer, r io.Reader) error {
+	count, err := readShort(r)
+	if err != nil {
+		return fmt.Errorf("voltdbclient: reading param count: %w", err)
+	}
+	for i := 0; i < int(count); i++ {
+		if _, err := fmt.Fprintf(w, "param[%d] ", i); err != nil {
+			return err
+		}
+		if err := dumpValue(w, r); err != nil {
+			return fmt.Errorf("voltdbclient: dumping param[%d]: %w", i, err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readShort(r io.Reader) (int16, error) {
+	var raw [2]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(raw[:])), nil
+}
+
+func readFloat(r io.Reader) (float64, error) {
+	var raw [8]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(raw[:])), nil
+}
+
+// dumpValue reads one tagged value from r - a VT* type byte followed
+// by its encoding - and writes its human-readable representation to
+// w, without a trailing newline.
+func dumpValue(w io.Writer, r io.Reader) error {
+	tag, err := readByte(r)
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case VTNull:
+		_, err = fmt.Fprint(w, "null")
+	case VTBool:
+		b, err := readByte(r)
+		if err != nil {
+			return err
+		}
+		if b == math.MinInt8 {
+			_, err = fmt.Fprint(w, "null<bool>")
+		} else {
+			_, err = fmt.Fprintf(w, "bool: %v", b != 0)
+		}
+		return err
+	case VTShort:
+		v, err := readShort(r)
+		if err != nil {
+			return err
+		}
+		if v == math.MinInt16 {
+			_, err = fmt.Fprint(w, "null<int16>")
+		} else {
+			_, err = fmt.Fprintf(w, "int16: %d", v)
+		}
+		return err
+	case VTInt:
+		v, err := readInt(r)
+		if err != nil {
+			return err
+		}
+		if v == math.MinInt32 {
+			_, err = fmt.Fprint(w, "null<int32>")
+		} else {
+			_, err = fmt.Fprintf(w, "int32: %d", v)
+		}
+		return err
+	case VTLong:
+		v, err := readLong(r)
+		if err != nil {
+			return err
+		}
+		if v == math.MinInt64 {
+			_, err = fmt.Fprint(w, "null<int64>")
+		} else {
+			_, err = fmt.Fprintf(w, "int64: %d", v)
+		}
+		return err
+	case VTFloat:
+		v, err := readFloat(r)
+		if err != nil {
+			return err
+		}
+		if v == -1.7E+308 {
+			_, err = fmt.Fprint(w, "null<float64>")
+		} else {
+			_, err = fmt.Fprintf(w, "float64: %v", v)
+		}
+		return err
+	case VTString:
+		n, err := readInt(r)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			_, err = fmt.Fprint(w, "null<string>")
+			return err
+		}
+		s := make([]byte, n)
+		if _, err := io.ReadFull(r, s); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "string: %q", s)
+		return err
+	case VTVarBin:
+		n, err := readInt(r)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			_, err = fmt.Fprint(w, "null<varbinary>")
+			return err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "varbinary: %x", b)
+		return err
+	case VTTimestamp:
+		var raw [8]byte
+		if _, err := io.ReadFull(r, raw[:]); err != nil {
+			return err
+		}
+		if raw == nullTimestamp {
+			_, err = fmt.Fprint(w, "null<timestamp>")
+		} else {
+			micros := int64(binary.BigEndian.Uint64(raw[:]))
+			_, err = fmt.Fprintf(w, "timestamp: %s", time.UnixMicro(micros).UTC().Format("2006-01-02T15:04:05Z"))
+		}
+		return err
+	case VTDecimal:
+		d, isNull, err := unmarshallDecimal(r)
+		if err != nil {
+			return err
+		}
+		if isNull {
+			_, err = fmt.Fprint(w, "null<decimal>")
+		} else {
+			_, err = fmt.Fprintf(w, "decimal: %s", d.FloatString(12))
+		}
+		return err
+	case VTGeographyPoint:
+		p, isNull, err := unmarshallGeographyPoint(r)
+		if err != nil {
+			return err
+		}
+		if isNull {
+			_, err = fmt.Fprint(w, "null<geography_point>")
+		} else {
+			_, err = fmt.Fprintf(w, "geography_point: (%v, %v)", p.Lng, p.Lat)
+		}
+		return err
+	case VTGeography:
+		poly, isNull, err := unmarshallGeography(r)
+		if err != nil {
+			return err
+		}
+		if isNull {
+			_, err = fmt.Fprint(w, "null<geography>")
+		} else {
+			_, err = fmt.Fprintf(w, "geography: %d loop(s)", len(poly.Loops))
+		}
+		return err
+	case VTArray:
+		return dumpArray(w, r)
+	default:
+		_, err = fmt.Fprintf(w, "<unknown type %d>", tag)
+	}
+	return err
+}
+
+// dumpArray reads an ARRAY value's element count and that many
+// tagged elements, rendering them as array<elemType>[n]: [a, b, ...].
+func dumpArray(w io.Writer, r io.Reader) error {
+	l, err := readShort(r)
+	if err != nil {
+		return err
+	}
+
+	var elems bytes.Buffer
+	elemType := "?"
+	for i := 0; i < int(l); i++ {
+		if i > 0 {
+			elems.WriteString(", ")
+		}
+		var one bytes.Buffer
+		if err := dumpValue(&one, r); err != nil {
+			return err
+		}
+		text := one.String()
+		if name, value, ok := splitTypeAndValue(text); ok {
+			elemType = name
+			elems.WriteString(value)
+		} else {
+			elems.WriteString(text)
+		}
+	}
+	_, err = fmt.Fprintf(w, "array<%s>[%d]: [%s]", elemType, l, elems.String())
+	return err
+}
+
+// splitTypeAndValue splits a dumpValue rendering of "type: value"
+// into its two halves, for dumpArray to lift the element type out to
+// the array<...> header.
+func splitTypeAndValue(text string) (typeName, value string, ok bool) {
+	i := bytes.IndexByte([]byte(text), ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return text[:i], text[i+2:], true
+}
+
+// LogParamsFunc, when set on a connection factory, is called with the
+// DumpParams text for every parameter set marshalled by that client -
+// the same debug-encoder pattern as encoding/prototext.
+type LogParamsFunc func(string)
+
+// logParams runs log over the DumpParams rendering of params, doing
+// nothing when log is nil.
+func logParams(log LogParamsFunc, params ...interface{}) {
+	if log == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := DumpParams(&buf, params...); err != nil {
+		log(fmt.Sprintf("voltdbclient: DumpParams failed: %v", err))
+		return
+	}
+	log(buf.String())
+}
@@ -0,0 +1,71 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestMarshallParamsFiresLogParamsFunc exercises the path a Client's
+// LogParamsFunc option actually runs on: marshallParams, invoked once
+// per procedure call, must call the supplied log with a DumpParams
+// rendering of the params it is about to write.
+func TestMarshallParamsFiresLogParamsFunc(t *testing.T) {
+	var logged []string
+	log := LogParamsFunc(func(s string) { logged = append(logged, s) })
+
+	var frame bytes.Buffer
+	if err := marshallParams(&frame, log, int32(42), "hi"); err != nil {
+		t.Fatalf("marshallParams: %v", err)
+	}
+
+	if len(logged) != 1 {
+		t.Fatalf("expected exactly one log call, got %d", len(logged))
+	}
+	if !strings.Contains(logged[0], "param[0] int32: 42") {
+		t.Fatalf("log text missing param[0]: %q", logged[0])
+	}
+	if !strings.Contains(logged[0], `param[1] string: "hi"`) {
+		t.Fatalf("log text missing param[1]: %q", logged[0])
+	}
+
+	// The frame marshallParams wrote is the same count-prefixed layout
+	// DumpFrame reads, so it must parse back to the same params.
+	var out bytes.Buffer
+	if err := DumpFrame(&out, bytes.NewReader(frame.Bytes())); err != nil {
+		t.Fatalf("DumpFrame: %v", err)
+	}
+	if !strings.Contains(out.String(), "param[0] int32: 42") {
+		t.Fatalf("DumpFrame output missing param[0]: %q", out.String())
+	}
+	if !strings.Contains(out.String(), `param[1] string: "hi"`) {
+		t.Fatalf("DumpFrame output missing param[1]: %q", out.String())
+	}
+}
+
+// TestMarshallParamsNilLog confirms a nil LogParamsFunc is a silent
+// no-op rather than a panic, so callers that don't set the option pay
+// nothing for it.
+func TestMarshallParamsNilLog(t *testing.T) {
+	var frame bytes.Buffer
+	if err := marshallParams(&frame, nil, int32(1)); err != nil {
+		t.Fatalf("marshallParams: %v", err)
+	}
+}
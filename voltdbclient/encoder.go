@@ -0,0 +1,179 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Encoder accumulates a parameter payload in an append-only []byte
+// rather than through the io.Writer interface, so a tight loop of
+// procedure invocations can reuse one backing slice instead of
+// allocating a fresh bytes.Buffer per call. Get an Encoder from the
+// package pool with GetEncoder, and return it with PutEncoder when
+// done; Reset lets a caller that already holds one reuse it directly.
+type Encoder struct {
+	buf []byte
+}
+
+var encoderPool = sync.Pool{
+	New: func() interface{} { return &Encoder{buf: make([]byte, 0, 256)} },
+}
+
+// GetEncoder returns an Encoder from the package pool, empty and
+// ready to append to.
+func GetEncoder() *Encoder {
+	return encoderPool.Get().(*Encoder)
+}
+
+// PutEncoder resets e and returns it to the package pool.
+func PutEncoder(e *Encoder) {
+	e.Reset()
+	encoderPool.Put(e)
+}
+
+// Reset empties e's backing slice without releasing its capacity, so
+// a caller driving repeated invocations can reuse one Encoder across
+// calls instead of round-tripping through the pool each time.
+func (e *Encoder) Reset() {
+	e.buf = e.buf[:0]
+}
+
+// Bytes returns the bytes appended to e so far. The slice is only
+// valid until the next call to Reset or an append method.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Len returns the number of bytes appended to e so far.
+func (e *Encoder) Len() int {
+	return len(e.buf)
+}
+
+func (e *Encoder) AppendByte(v int8) {
+	e.buf = appendByte(e.buf, v)
+}
+
+func (e *Encoder) AppendBoolean(v bool) {
+	e.buf = appendBoolean(e.buf, v)
+}
+
+func (e *Encoder) AppendShort(v int16) {
+	e.buf = appendShort(e.buf, v)
+}
+
+func (e *Encoder) AppendInt32(v int32) {
+	e.buf = appendInt32(e.buf, v)
+}
+
+func (e *Encoder) AppendInt64(v int64) {
+	e.buf = appendInt64(e.buf, v)
+}
+
+func (e *Encoder) AppendFloat64(v float64) {
+	e.buf = appendFloat64(e.buf, v)
+}
+
+func (e *Encoder) AppendString(v string) {
+	e.buf = appendString(e.buf, v)
+}
+
+func (e *Encoder) AppendVarbinary(v []byte) {
+	e.buf = appendVarbinary(e.buf, v)
+}
+
+// AppendRaw appends v unchanged, for callers (such as registered
+// Marshallers) that already hold their encoded bytes.
+func (e *Encoder) AppendRaw(v []byte) {
+	e.buf = append(e.buf, v...)
+}
+
+// BeginLengthPrefixed appends a placeholder 4-byte length field and
+// returns its offset, to be passed to EndLengthPrefixed once the
+// framed content has been appended.
+func (e *Encoder) BeginLengthPrefixed() int {
+	fixup := len(e.buf)
+	e.buf = appendInt32(e.buf, 0)
+	return fixup
+}
+
+// EndLengthPrefixed patches the placeholder written by
+// BeginLengthPrefixed with the number of bytes appended since.
+func (e *Encoder) EndLengthPrefixed(fixup int) {
+	length := int32(len(e.buf) - fixup - 4)
+	binary.BigEndian.PutUint32(e.buf[fixup:fixup+4], uint32(length))
+}
+
+// Writer adapts e to the io.Writer interface, for code (such as a
+// registered Marshaller) that only knows how to write to a stream.
+// Writes through it append directly to e's backing slice.
+func (e *Encoder) Writer() *encoderWriter {
+	return &encoderWriter{e}
+}
+
+type encoderWriter struct {
+	e *Encoder
+}
+
+func (w *encoderWriter) Write(p []byte) (int, error) {
+	w.e.buf = append(w.e.buf, p...)
+	return len(p), nil
+}
+
+// The VoltDB wire protocol is big-endian throughout; these mirror the
+// write* helpers' layout but append to a slice instead of an
+// io.Writer, following the style of protowire's AppendVarint family.
+
+func appendByte(b []byte, v int8) []byte {
+	return append(b, byte(v))
+}
+
+func appendBoolean(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func appendShort(b []byte, v int16) []byte {
+	return binary.BigEndian.AppendUint16(b, uint16(v))
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+func appendFloat64(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func appendString(b []byte, v string) []byte {
+	b = appendInt32(b, int32(len(v)))
+	return append(b, v...)
+}
+
+func appendVarbinary(b []byte, v []byte) []byte {
+	b = appendInt32(b, int32(len(v)))
+	return append(b, v...)
+}
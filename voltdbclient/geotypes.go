@@ -0,0 +1,236 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+// VoltDB's DECIMAL columns are 16-byte fixed point values scaled by
+// 10^12; GEOGRAPHY_POINT and GEOGRAPHY are the two spatial column
+// types. These are not yet assigned VT* constants elsewhere in the
+// package, so they're added here alongside their marshalling.
+const (
+	VTDecimal        int8 = 22
+	VTGeographyPoint int8 = 35
+	VTGeography      int8 = 36
+)
+
+// decimalScale is 10^12, the fixed scale VoltDB uses for DECIMAL.
+var decimalScale = big.NewInt(1000000000000)
+
+// decimalNull is the 16-byte DECIMAL null sentinel: 0x80 followed by
+// fifteen 0x00 bytes.
+var decimalNull = [16]byte{0x80}
+
+// geoPointNull is the GEOGRAPHY_POINT null sentinel: both coordinates
+// set to the out-of-range value 360.
+const geoPointNull = 360.0
+
+// Point is a GEOGRAPHY_POINT value.
+type Point struct {
+	Lng float64
+	Lat float64
+}
+
+// Polygon is a GEOGRAPHY value: an outer loop followed by zero or more
+// hole loops, each a closed ring of vertices in VoltDB's loop-oriented
+// WKB-like encoding (counter-clockwise outer loop, clockwise holes).
+type Polygon struct {
+	Loops [][]Point
+}
+
+func marshallDecimal(buf io.Writer, d *big.Rat) (err error) {
+	writeByte(buf, VTDecimal)
+	return writeDecimalBytes(buf, d)
+}
+
+func writeDecimalBytes(buf io.Writer, d *big.Rat) error {
+	scaled := new(big.Int).Mul(d.Num(), decimalScale)
+	scaled.Quo(scaled, d.Denom())
+
+	b := scaled.Bytes()
+	// b is an unsigned magnitude; a 16-byte two's-complement field can
+	// only hold it unambiguously as a positive value if its top bit is
+	// free, otherwise it would be reinterpreted as negative (and could
+	// even collide with the decimalNull sentinel).
+	if len(b) > 16 || (len(b) == 16 && b[0]&0x80 != 0) {
+		return errors.New("voltdbclient: DECIMAL value out of range")
+	}
+
+	var out [16]byte
+	// big.Int.Bytes is unsigned big-endian magnitude; lay it into the
+	// low-order bytes of the 16-byte field, then two's-complement the
+	// whole field if the value is negative.
+	copy(out[16-len(b):], b)
+	if scaled.Sign() < 0 {
+		twosComplement(&out)
+	}
+	_, err := buf.Write(out[:])
+	return err
+}
+
+// twosComplement flips b in place to its two's-complement negation:
+// invert every bit, then add one with carry.
+func twosComplement(b *[16]byte) {
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	carry := uint16(1)
+	for i := 15; i >= 0 && carry > 0; i-- {
+		sum := uint16(b[i]) + carry
+		b[i] = byte(sum)
+		carry = sum >> 8
+	}
+}
+
+// decimalFromValue accepts the decimal representations marshallValueTo
+// encodes as DECIMAL: big.Rat and decimal.Decimal, by value or pointer.
+func decimalFromValue(v interface{}) (*big.Rat, bool) {
+	switch d := v.(type) {
+	case big.Rat:
+		return &d, true
+	case *big.Rat:
+		return d, true
+	case decimal.Decimal:
+		return d.Rat(), true
+	case *decimal.Decimal:
+		return d.Rat(), true
+	default:
+		return nil, false
+	}
+}
+
+func marshallGeographyPoint(buf io.Writer, p Point) (err error) {
+	writeByte(buf, VTGeographyPoint)
+	if err = binary.Write(buf, binary.LittleEndian, p.Lng); err != nil {
+		return
+	}
+	return binary.Write(buf, binary.LittleEndian, p.Lat)
+}
+
+func marshallGeography(buf io.Writer, poly Polygon) (err error) {
+	writeByte(buf, VTGeography)
+
+	if err = writeInt(buf, int32(len(poly.Loops))); err != nil {
+		return
+	}
+	for _, loop := range poly.Loops {
+		if err = writeInt(buf, int32(len(loop))); err != nil {
+			return
+		}
+		for _, pt := range loop {
+			if err = binary.Write(buf, binary.LittleEndian, pt.Lng); err != nil {
+				return
+			}
+			if err = binary.Write(buf, binary.LittleEndian, pt.Lat); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func marshallNullDecimal(buf io.Writer) {
+	writeByte(buf, VTDecimal)
+	buf.Write(decimalNull[:])
+}
+
+func marshallNullGeographyPoint(buf io.Writer) {
+	writeByte(buf, VTGeographyPoint)
+	binary.Write(buf, binary.LittleEndian, geoPointNull)
+	binary.Write(buf, binary.LittleEndian, geoPointNull)
+}
+
+func marshallNullGeography(buf io.Writer) {
+	writeByte(buf, VTGeography)
+	writeInt(buf, int32(-1))
+}
+
+// unmarshallDecimal reads a 16-byte DECIMAL field, returning (nil,
+// true) for the null sentinel.
+func unmarshallDecimal(r io.Reader) (d *big.Rat, isNull bool, err error) {
+	var raw [16]byte
+	if _, err = io.ReadFull(r, raw[:]); err != nil {
+		return
+	}
+	if raw == decimalNull {
+		return nil, true, nil
+	}
+
+	negative := raw[0]&0x80 != 0
+	if negative {
+		twosComplement(&raw)
+	}
+	mag := new(big.Int).SetBytes(raw[:])
+	if negative {
+		mag.Neg(mag)
+	}
+	return new(big.Rat).SetFrac(mag, decimalScale), false, nil
+}
+
+// unmarshallGeographyPoint reads a GEOGRAPHY_POINT field, returning
+// (Point{}, true) for the null sentinel.
+func unmarshallGeographyPoint(r io.Reader) (p Point, isNull bool, err error) {
+	if err = binary.Read(r, binary.LittleEndian, &p.Lng); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.LittleEndian, &p.Lat); err != nil {
+		return
+	}
+	if p.Lng == geoPointNull && p.Lat == geoPointNull {
+		return Point{}, true, nil
+	}
+	return p, false, nil
+}
+
+// unmarshallGeography reads a GEOGRAPHY field's loops, returning (nil,
+// true) for the null sentinel (a loop count of -1).
+func unmarshallGeography(r io.Reader) (poly Polygon, isNull bool, err error) {
+	numLoops, err := readInt(r)
+	if err != nil {
+		return
+	}
+	if numLoops < 0 {
+		return Polygon{}, true, nil
+	}
+
+	poly.Loops = make([][]Point, numLoops)
+	for i := range poly.Loops {
+		numVerts, err := readInt(r)
+		if err != nil {
+			return poly, false, err
+		}
+		loop := make([]Point, numVerts)
+		for j := range loop {
+			if err := binary.Read(r, binary.LittleEndian, &loop[j].Lng); err != nil {
+				return poly, false, err
+			}
+			if err := binary.Read(r, binary.LittleEndian, &loop[j].Lat); err != nil {
+				return poly, false, err
+			}
+		}
+		poly.Loops[i] = loop
+	}
+	return poly, false, nil
+}
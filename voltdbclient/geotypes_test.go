@@ -0,0 +1,189 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestMarshallDecimalGoldenBytes(t *testing.T) {
+	cases := []struct {
+		name string
+		rat  *big.Rat
+		want []byte
+	}{
+		{"zero", big.NewRat(0, 1), append([]byte{byte(VTDecimal)}, make([]byte, 16)...)},
+		{"one", big.NewRat(1, 1), append([]byte{byte(VTDecimal)}, rightAligned(big.NewInt(1000000000000))...)},
+		{"negative one", big.NewRat(-1, 1), append([]byte{byte(VTDecimal)}, negativeDecimalBytes(big.NewInt(1000000000000))...)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := marshallDecimal(&buf, c.rat); err != nil {
+				t.Fatalf("marshallDecimal: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), c.want) {
+				t.Fatalf("got % x, want % x", buf.Bytes(), c.want)
+			}
+		})
+	}
+}
+
+// rightAligned lays v's unsigned big-endian bytes into the low-order
+// bytes of a 16-byte field, matching writeDecimalBytes for positive
+// values.
+func rightAligned(v *big.Int) []byte {
+	var out [16]byte
+	b := v.Bytes()
+	copy(out[16-len(b):], b)
+	return out[:]
+}
+
+// negativeDecimalBytes two's-complements rightAligned(v), matching
+// writeDecimalBytes for negative values.
+func negativeDecimalBytes(v *big.Int) []byte {
+	out := [16]byte{}
+	copy(out[:], rightAligned(v))
+	twosComplement(&out)
+	return out[:]
+}
+
+func TestUnmarshallDecimalRoundTrip(t *testing.T) {
+	vals := []*big.Rat{
+		big.NewRat(0, 1),
+		big.NewRat(1, 1),
+		big.NewRat(-1, 1),
+		big.NewRat(355, 100), // 3.55
+		big.NewRat(-355, 100),
+	}
+	for _, want := range vals {
+		var buf bytes.Buffer
+		if err := writeDecimalBytes(&buf, want); err != nil {
+			t.Fatalf("writeDecimalBytes(%v): %v", want, err)
+		}
+		got, isNull, err := unmarshallDecimal(&buf)
+		if err != nil {
+			t.Fatalf("unmarshallDecimal(%v): %v", want, err)
+		}
+		if isNull {
+			t.Fatalf("unmarshallDecimal(%v): unexpected null", want)
+		}
+		if got.Cmp(want) != 0 {
+			t.Fatalf("round-trip %v: got %v", want, got)
+		}
+	}
+}
+
+func TestUnmarshallDecimalNull(t *testing.T) {
+	_, isNull, err := unmarshallDecimal(bytes.NewReader(decimalNull[:]))
+	if err != nil {
+		t.Fatalf("unmarshallDecimal: %v", err)
+	}
+	if !isNull {
+		t.Fatal("expected decimalNull to round-trip as null")
+	}
+}
+
+func TestWriteDecimalBytesOutOfRange(t *testing.T) {
+	// 2^127 has a 16-byte unsigned magnitude with the top bit set, so it
+	// cannot be told apart from a negative two's-complement value.
+	tooBig := new(big.Int).Lsh(big.NewInt(1), 127)
+	// writeDecimalBytes multiplies by decimalScale itself, so pass
+	// 2^127 / 10^12 to make the scaled value exactly 2^127.
+	scaled := new(big.Rat).SetFrac(tooBig, decimalScale)
+	if err := writeDecimalBytes(&bytes.Buffer{}, scaled); err == nil {
+		t.Fatal("expected out-of-range error for a 16-byte positive magnitude with its top bit set")
+	}
+}
+
+func TestMarshallGeographyPointGoldenBytes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := marshallGeographyPoint(&buf, Point{Lng: 1, Lat: -2}); err != nil {
+		t.Fatalf("marshallGeographyPoint: %v", err)
+	}
+	// VTGeographyPoint tag, then Lng=1 and Lat=-2 as little-endian float64s.
+	want := []byte{
+		byte(VTGeographyPoint),
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, // 1.0
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0, // -2.0
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("got % x, want % x", buf.Bytes(), want)
+	}
+
+	p, isNull, err := unmarshallGeographyPoint(bytes.NewReader(buf.Bytes()[1:]))
+	if err != nil {
+		t.Fatalf("unmarshallGeographyPoint: %v", err)
+	}
+	if isNull || p != (Point{Lng: 1, Lat: -2}) {
+		t.Fatalf("round-trip: got %+v, isNull=%v", p, isNull)
+	}
+}
+
+func TestMarshallGeographyPointNull(t *testing.T) {
+	var buf bytes.Buffer
+	marshallNullGeographyPoint(&buf)
+	p, isNull, err := unmarshallGeographyPoint(bytes.NewReader(buf.Bytes()[1:]))
+	if err != nil {
+		t.Fatalf("unmarshallGeographyPoint: %v", err)
+	}
+	if !isNull || p != (Point{}) {
+		t.Fatalf("expected null Point{}, got %+v isNull=%v", p, isNull)
+	}
+}
+
+func TestMarshallGeographyRoundTrip(t *testing.T) {
+	poly := Polygon{Loops: [][]Point{
+		{{Lng: 0, Lat: 0}, {Lng: 1, Lat: 0}, {Lng: 1, Lat: 1}, {Lng: 0, Lat: 0}},
+	}}
+
+	var buf bytes.Buffer
+	if err := marshallGeography(&buf, poly); err != nil {
+		t.Fatalf("marshallGeography: %v", err)
+	}
+
+	got, isNull, err := unmarshallGeography(bytes.NewReader(buf.Bytes()[1:]))
+	if err != nil {
+		t.Fatalf("unmarshallGeography: %v", err)
+	}
+	if isNull {
+		t.Fatal("unexpected null")
+	}
+	if len(got.Loops) != 1 || len(got.Loops[0]) != 4 {
+		t.Fatalf("got %+v", got)
+	}
+	for i, pt := range got.Loops[0] {
+		if pt != poly.Loops[0][i] {
+			t.Fatalf("loop[0][%d]: got %+v, want %+v", i, pt, poly.Loops[0][i])
+		}
+	}
+}
+
+func TestMarshallGeographyNull(t *testing.T) {
+	var buf bytes.Buffer
+	marshallNullGeography(&buf)
+	_, isNull, err := unmarshallGeography(bytes.NewReader(buf.Bytes()[1:]))
+	if err != nil {
+		t.Fatalf("unmarshallGeography: %v", err)
+	}
+	if !isNull {
+		t.Fatal("expected null Polygon")
+	}
+}
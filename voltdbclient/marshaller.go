@@ -0,0 +1,102 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Marshaller lets a type outside this package describe its own wire
+// encoding, so marshallValue does not need to know about it. Implement
+// it and call RegisterType to teach the client a new Go type. A single
+// Marshaller is a stateless codec registered once for a type (or an
+// interface many types satisfy) and reused for every value of that
+// type; MarshalVolt receives the specific value to encode each time it
+// is called, via v.
+type Marshaller interface {
+	// VoltType returns the VoltDB column type byte (one of the VT*
+	// constants) the value should be tagged with on the wire.
+	VoltType() int8
+	// MarshalVolt writes v's encoding to w, not including the leading
+	// VoltType byte, which marshallValue writes itself. v is the
+	// concrete parameter value being marshalled, not the Marshaller
+	// that was registered for its type.
+	MarshalVolt(w io.Writer, v interface{}) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[reflect.Type]Marshaller{}
+	// ifaces tracks the interface types registered in registry, in
+	// registration order, so interface-satisfaction lookup in
+	// lookupMarshaller is deterministic instead of following map
+	// iteration order.
+	ifaces []reflect.Type
+)
+
+// RegisterType associates t with a Marshaller consulted by
+// marshallValue before it falls back to its built-in panics. Later
+// calls for the same t replace the previous registration.
+//
+// Lookup order in marshallValue is: exact type, then pointer-to t (so
+// a Marshaller registered for *T also covers T values; a *T parameter
+// is dereferenced first, so registering T covers *T), then, for interface types,
+// in the order they were registered, the first one the value satisfies.
+func RegisterType(t reflect.Type, m Marshaller) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if t.Kind() == reflect.Interface {
+		if _, ok := registry[t]; !ok {
+			ifaces = append(ifaces, t)
+		}
+	}
+	registry[t] = m
+}
+
+// lookupMarshaller finds the Marshaller registered for v, if any,
+// following the exact-type -> pointer-to -> interface-satisfaction
+// order documented on RegisterType.
+func lookupMarshaller(v reflect.Value, t reflect.Type) Marshaller {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if m, ok := registry[t]; ok {
+		return m
+	}
+	if m, ok := registry[reflect.PtrTo(t)]; ok {
+		return m
+	}
+	for _, rt := range ifaces {
+		if t.Implements(rt) {
+			return registry[rt]
+		}
+	}
+	return nil
+}
+
+// marshallRegistered writes v through the Marshaller m registered for
+// its type: m's VoltType byte, then v's own encoding via
+// m.MarshalVolt(buf, v).
+func marshallRegistered(buf io.Writer, m Marshaller, v reflect.Value) (err error) {
+	if err = writeByte(buf, m.VoltType()); err != nil {
+		return
+	}
+	return m.MarshalVolt(buf, v.Interface())
+}
@@ -33,7 +33,22 @@ import (
 // A helper for protocol-level de/serialization code. For
 // example, serialize and write a procedure call to the network.
 
+// serializeLoginMessage builds the login frame for the HASHED auth
+// scheme, as it always has. It is a thin shim over
+// serializeLoginMessageAuth for existing callers that don't yet pass
+// an AuthConfig.
 func serializeLoginMessage(protocolVersion int, user string, passwd string) (msg bytes.Buffer, err error) {
+	return serializeLoginMessageAuth(protocolVersion, user, passwd, nil)
+}
+
+// serializeLoginMessageAuth builds the login frame for auth.Scheme
+// (AuthHashed when auth is nil, which is equivalent to
+// &AuthConfig{Scheme: AuthHashed}).
+func serializeLoginMessageAuth(protocolVersion int, user string, passwd string, auth *AuthConfig) (msg bytes.Buffer, err error) {
+	if auth != nil && auth.Scheme == AuthKerberos {
+		return serializeKerberosLoginMessage(user, auth)
+	}
+
 	var h hash.Hash
 	if protocolVersion == 0 {
 		h = sha1.New()
@@ -59,8 +74,38 @@ func serializeLoginMessage(protocolVersion int, user string, passwd string) (msg
 	return
 }
 
-// configures conn with server's advertisement.
+// serializeKerberosLoginMessage builds the login frame for the
+// KERBEROS auth scheme: the password hash is replaced by a
+// SPNEGO-wrapped AP-REQ token for auth.ServicePrincipal.
+func serializeKerberosLoginMessage(user string, auth *AuthConfig) (msg bytes.Buffer, err error) {
+	err = writeString(&msg, "database")
+	if err != nil {
+		return
+	}
+	err = writeString(&msg, user)
+	if err != nil {
+		return
+	}
+	err = writeKerberosToken(&msg, auth)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// deserializeLoginResponse configures conn with server's advertisement
+// for the HASHED auth scheme, as it always has. It is a thin shim over
+// deserializeLoginResponseAuth for existing callers that don't yet
+// pass an AuthConfig.
 func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error) {
+	return deserializeLoginResponseAuth(r, nil)
+}
+
+// deserializeLoginResponseAuth configures conn with server's
+// advertisement. When auth negotiates KERBEROS, the server's
+// mutual-authentication reply token is consumed before the rest of the
+// frame is read.
+func deserializeLoginResponseAuth(r io.Reader, auth *AuthConfig) (connData *connectionData, err error) {
 	// Authentication result code	Byte	 1	 Basic
 	// Server Host ID	            Integer	 4	 Basic
 	// Connection ID	            Long	 8	 Basic
@@ -75,6 +120,12 @@ func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error)
 		return nil, errors.New("Authentication failed.")
 	}
 
+	if auth != nil && auth.Scheme == AuthKerberos {
+		if _, err = readVarbinary(r); err != nil {
+			return
+		}
+	}
+
 	hostID, err := readInt(r)
 	if err != nil {
 		return
@@ -108,110 +159,128 @@ func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error)
 	return connData, nil
 }
 
+// marshallParam writes param's VoltDB wire encoding to buf. It drives
+// an Encoder under the hood so repeated calls in a tight invocation
+// loop allocate once; callers marshalling many parameters in a row
+// should prefer marshallParamTo directly on a shared Encoder instead.
 func marshallParam(buf io.Writer, param interface{}) (err error) {
+	enc := GetEncoder()
+	defer PutEncoder(enc)
+	if err = marshallParamTo(enc, param); err != nil {
+		return
+	}
+	_, err = buf.Write(enc.Bytes())
+	return
+}
+
+// marshallParams writes a full parameter-set frame for one procedure
+// invocation to buf: a 2-byte parameter count followed by each
+// param's encoding, the layout marshallSliceTo also uses for ARRAY
+// columns and DumpFrame reads back. When log is non-nil, it is handed
+// a DumpParams rendering of params first, so a Client's LogParamsFunc
+// option actually fires once per invocation instead of sitting unused.
+func marshallParams(buf io.Writer, log LogParamsFunc, params ...interface{}) (err error) {
+	logParams(log, params...)
+
+	enc := GetEncoder()
+	defer PutEncoder(enc)
+	enc.AppendShort(int16(len(params)))
+	for _, p := range params {
+		if err = marshallParamTo(enc, p); err != nil {
+			return
+		}
+	}
+	_, err = buf.Write(enc.Bytes())
+	return
+}
+
+// marshallParamTo appends param's VoltDB wire encoding to enc.
+func marshallParamTo(enc *Encoder, param interface{}) (err error) {
 	if param == nil {
-		marshallNil(buf)
+		enc.AppendByte(VTNull)
 		return
 	}
 	v := reflect.ValueOf(param)
 	t := reflect.TypeOf(param)
-	err = marshallValue(buf, v, t)
-	return
+	return marshallValueTo(enc, v, t)
 }
 
 func marshallNil(buf io.Writer) {
 	writeByte(buf, VTNull)
 }
 
+// marshallValue writes v's VoltDB wire encoding to buf via a
+// one-shot Encoder; it exists as a thin shim over marshallValueTo for
+// callers (and registered Marshallers) that only have an io.Writer.
 func marshallValue(buf io.Writer, v reflect.Value, t reflect.Type) (err error) {
+	enc := GetEncoder()
+	defer PutEncoder(enc)
+	if err = marshallValueTo(enc, v, t); err != nil {
+		return
+	}
+	_, err = buf.Write(enc.Bytes())
+	return
+}
+
+func marshallValueTo(enc *Encoder, v reflect.Value, t reflect.Type) (err error) {
 	if !v.IsValid() {
 		return errors.New("Can not encode value.")
 	}
 	switch v.Kind() {
 	case reflect.Bool:
-		marshallBool(buf, v)
+		enc.AppendByte(VTBool)
+		enc.AppendBoolean(v.Bool())
 	case reflect.Int8:
-		marshallInt8(buf, v)
+		enc.AppendByte(VTBool)
+		enc.AppendByte(int8(v.Int()))
 	case reflect.Int16:
-		marshallInt16(buf, v)
+		enc.AppendByte(VTShort)
+		enc.AppendShort(int16(v.Int()))
 	case reflect.Int32:
-		marshallInt32(buf, v)
+		enc.AppendByte(VTInt)
+		enc.AppendInt32(int32(v.Int()))
 	case reflect.Int64:
-		marshallInt64(buf, v)
+		enc.AppendByte(VTLong)
+		enc.AppendInt64(v.Int())
 	case reflect.Float64:
-		marshallFloat64(buf, v)
+		enc.AppendByte(VTFloat)
+		enc.AppendFloat64(v.Float())
 	case reflect.String:
-		marshallString(buf, v)
+		enc.AppendByte(VTString)
+		enc.AppendString(v.String())
 	case reflect.Slice:
 		l := v.Len()
 		x := v.Slice(0, l)
-		err = marshallSlice(buf, x, t, l)
+		err = marshallSliceTo(enc, x, t, l)
 	case reflect.Struct:
-		if t, ok := v.Interface().(time.Time); ok {
-			marshallTimestamp(buf, t)
+		if tm, ok := v.Interface().(time.Time); ok {
+			marshallTimestamp(enc.Writer(), tm)
 		} else if nv, ok := v.Interface().(nullValue); ok {
-			marshallNullValue(buf, nv)
+			marshallNullValue(enc.Writer(), nv)
+		} else if d, ok := decimalFromValue(v.Interface()); ok {
+			err = marshallDecimal(enc.Writer(), d)
+		} else if p, ok := v.Interface().(Point); ok {
+			err = marshallGeographyPoint(enc.Writer(), p)
+		} else if poly, ok := v.Interface().(Polygon); ok {
+			err = marshallGeography(enc.Writer(), poly)
+		} else if m := lookupMarshaller(v, t); m != nil {
+			err = marshallRegistered(enc.Writer(), m, v)
 		} else {
 			panic("Can't marshal struct-type parameters")
 		}
 	case reflect.Ptr:
 		deref := v.Elem()
-		marshallValue(buf, deref, deref.Type())
+		err = marshallValueTo(enc, deref, deref.Type())
 	default:
-		panic(fmt.Sprintf("Can't marshal %v-type parameters", v.Kind()))
+		if m := lookupMarshaller(v, t); m != nil {
+			err = marshallRegistered(enc.Writer(), m, v)
+		} else {
+			panic(fmt.Sprintf("Can't marshal %v-type parameters", v.Kind()))
+		}
 	}
 	return
 }
 
-func marshallBool(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Bool()
-	writeByte(buf, VTBool)
-	err = writeBoolean(buf, x)
-	return
-}
-
-func marshallInt8(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Int()
-	writeByte(buf, VTBool)
-	err = writeByte(buf, int8(x))
-	return
-}
-
-func marshallInt16(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Int()
-	writeByte(buf, VTShort)
-	err = writeShort(buf, int16(x))
-	return
-}
-
-func marshallInt32(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Int()
-	writeByte(buf, VTInt)
-	err = writeInt(buf, int32(x))
-	return
-}
-
-func marshallInt64(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Int()
-	writeByte(buf, VTLong)
-	err = writeLong(buf, int64(x))
-	return
-}
-
-func marshallFloat64(buf io.Writer, v reflect.Value) (err error) {
-	x := v.Float()
-	writeByte(buf, VTFloat)
-	err = writeFloat(buf, float64(x))
-	return
-}
-
-func marshallString(buf io.Writer, v reflect.Value) (err error) {
-	x := v.String()
-	writeByte(buf, VTString)
-	err = writeString(buf, x)
-	return
-}
-
 func marshallTimestamp(buf io.Writer, t time.Time) (err error) {
 	writeByte(buf, VTTimestamp)
 	writeTimestamp(buf, t)
@@ -244,26 +313,44 @@ func marshallNullValue(buf io.Writer, nv nullValue) (err error) {
 	case VTTimestamp:
 		writeByte(buf, VTTimestamp)
 		buf.Write(nullTimestamp[:])
+	case VTDecimal:
+		marshallNullDecimal(buf)
+	case VTGeographyPoint:
+		marshallNullGeographyPoint(buf)
+	case VTGeography:
+		marshallNullGeography(buf)
 	default:
 		panic(fmt.Sprintf("Unexpected null type %d", nv.getColType()))
 	}
 	return
 }
 
+// marshallSlice writes v's VoltDB wire encoding to buf via a
+// one-shot Encoder; it exists as a thin shim over marshallSliceTo for
+// callers that only have an io.Writer.
 func marshallSlice(buf io.Writer, v reflect.Value, t reflect.Type, l int) (err error) {
+	enc := GetEncoder()
+	defer PutEncoder(enc)
+	if err = marshallSliceTo(enc, v, t, l); err != nil {
+		return
+	}
+	_, err = buf.Write(enc.Bytes())
+	return
+}
+
+func marshallSliceTo(enc *Encoder, v reflect.Value, t reflect.Type, l int) (err error) {
 	k := t.Elem().Kind()
 
 	// distinguish between byte arrays and all other slices.
 	// byte arrays are handled as VARBINARY, all others are handled as ARRAY.
 	if k == reflect.Uint8 {
-		bs := v.Bytes()
-		writeByte(buf, VTVarBin)
-		err = writeVarbinary(buf, bs)
+		enc.AppendByte(VTVarBin)
+		enc.AppendVarbinary(v.Bytes())
 	} else {
-		writeByte(buf, VTArray)
-		writeShort(buf, int16(l))
+		enc.AppendByte(VTArray)
+		enc.AppendShort(int16(l))
 		for i := 0; i < l; i++ {
-			err = marshallValue(buf, v.Index(i), t)
+			err = marshallValueTo(enc, v.Index(i), t)
 		}
 	}
 	return
@@ -0,0 +1,158 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+var errUpgradeRefused = errors.New("voltdbclient: server refused StartTLS upgrade")
+
+// dialVolt opens the TCP connection used for the VoltDB wire protocol,
+// optionally wrapping it in TLS. When cfg is nil the connection is
+// plaintext, matching the client's historical behavior.
+func dialVolt(network, addr string, timeout time.Duration, cfg *TLSConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	if cfg == nil {
+		return dialer.Dial(network, addr)
+	}
+
+	if cfg.StartTLS {
+		conn, err := dialer.Dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return startTLS(conn, addr, cfg)
+	}
+
+	tlsCfg, err := cfg.clientConfig(addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.DialWithDialer(dialer, network, addr, tlsCfg)
+}
+
+// TLSConfig configures TLS wire encryption for the connection to a
+// VoltDB server. A nil *TLSConfig on the connection factory leaves the
+// socket in plaintext. Build one with NewTLSConfig.
+type TLSConfig struct {
+	// Config is used as-is to establish the TLS session, except that
+	// ServerName is defaulted from the dial address when unset so SNI
+	// is sent without callers needing to split host:port themselves.
+	// Custom root CAs and mTLS client certificates are configured the
+	// same way as any other tls.Config: RootCAs and Certificates.
+	Config *tls.Config
+
+	// StartTLS defers the TLS handshake until after the connection is
+	// opened in plaintext, for servers that negotiate the upgrade on
+	// the same port rather than requiring a dedicated TLS listener. Set
+	// via WithStartTLS.
+	StartTLS bool
+}
+
+// TLSOption configures a TLSConfig built by NewTLSConfig.
+type TLSOption func(*TLSConfig)
+
+// NewTLSConfig builds the TLSConfig option for the connection factory.
+// With no opts, it dials a full TLS handshake up front, equivalent to
+// tls.Dial.
+func NewTLSConfig(opts ...TLSOption) *TLSConfig {
+	cfg := &TLSConfig{Config: &tls.Config{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithStartTLS defers the handshake until the server, on an
+// already-open plaintext connection, sends its one-byte upgrade
+// acknowledgment, for deployments that negotiate TLS on the same port
+// rather than a dedicated TLS listener. The client sends no upgrade
+// request of its own.
+func WithStartTLS() TLSOption {
+	return func(cfg *TLSConfig) { cfg.StartTLS = true }
+}
+
+// clientConfig returns cfg.Config with ServerName filled in from addr
+// when the caller didn't set one.
+func (cfg *TLSConfig) clientConfig(addr string) (*tls.Config, error) {
+	base := cfg.Config
+	if base == nil {
+		base = &tls.Config{}
+	}
+	if base.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		c := base.Clone()
+		c.ServerName = host
+		base = c
+	}
+	return base, nil
+}
+
+// startTLS performs the StartTLS upgrade on the plaintext conn: it
+// waits for the server's upgrade acknowledgment, then completes the TLS
+// handshake over the same connection before returning, so the
+// length-prefixed VoltDB login handshake that follows runs entirely
+// inside the encrypted stream.
+func startTLS(conn net.Conn, addr string, cfg *TLSConfig) (net.Conn, error) {
+	tlsCfg, err := cfg.clientConfig(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The server acknowledges the upgrade with a single byte (0 == ok)
+	// before the TLS ClientHello may be sent.
+	br := bufio.NewReader(conn)
+	ack, err := br.ReadByte()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ack != 0 {
+		conn.Close()
+		return nil, errUpgradeRefused
+	}
+
+	tlsConn := tls.Client(&bufferedConn{Conn: conn, r: br}, tlsCfg)
+	if err := tlsConn.Handshake(); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// bufferedConn lets a net.Conn keep serving reads out of a bufio.Reader
+// that may already have buffered bytes past the StartTLS ack, while
+// still exposing the full net.Conn interface tls.Client needs.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -0,0 +1,78 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2016 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fragmentingReader hands back at most max bytes per Read, simulating
+// a TLS record boundary splitting a logical frame across reads.
+type fragmentingReader struct {
+	r   *bytes.Reader
+	max int
+}
+
+func (f *fragmentingReader) Read(p []byte) (int, error) {
+	if len(p) > f.max {
+		p = p[:f.max]
+	}
+	return f.r.Read(p)
+}
+
+// TestDeserializeLoginResponseFragmented verifies the build string and
+// the rest of the login response still parse correctly when delivered
+// a few bytes at a time, the way a TLS stream hands data to a Reader
+// in record-sized chunks rather than one logical frame at a time.
+func TestDeserializeLoginResponseFragmented(t *testing.T) {
+	var buf bytes.Buffer
+	writeByte(&buf, 0)         // authentication ok
+	writeInt(&buf, 7)          // host ID
+	writeLong(&buf, 42)        // connection ID
+	writeLong(&buf, 1000)      // cluster start timestamp
+	writeInt(&buf, 0x7f000001) // leader address
+	writeString(&buf, "voltdb-8.4 abcdef1234")
+
+	for _, max := range []int{1, 2, 3, 7} {
+		r := &fragmentingReader{r: bytes.NewReader(buf.Bytes()), max: max}
+		connData, err := deserializeLoginResponse(r)
+		if err != nil {
+			t.Fatalf("max=%d: deserializeLoginResponse: %v", max, err)
+		}
+		if connData.hostID != 7 || connData.connID != 42 || connData.leaderAddr != 0x7f000001 {
+			t.Fatalf("max=%d: got %+v", max, connData)
+		}
+		if connData.buildString != "voltdb-8.4 abcdef1234" {
+			t.Fatalf("max=%d: got build string %q", max, connData.buildString)
+		}
+	}
+}
+
+var _ io.Reader = (*fragmentingReader)(nil)
+
+func TestWithStartTLS(t *testing.T) {
+	cfg := NewTLSConfig(WithStartTLS())
+	if !cfg.StartTLS {
+		t.Fatal("expected WithStartTLS to set StartTLS")
+	}
+	if cfg.Config == nil {
+		t.Fatal("expected NewTLSConfig to set a default Config")
+	}
+}